@@ -0,0 +1,44 @@
+package parallel
+
+import (
+	"go.uber.org/atomic"
+
+	pb "github.com/lindb/lindb/rpc/proto/common"
+)
+
+// resultMerger is the merge behavior newTaskContext's last argument needs to
+// provide; newResultMerger, newStreamingResultMerger and newSuggestResultMerger
+// all satisfy it.
+type resultMerger interface {
+	merge(resp *pb.TaskResponse)
+}
+
+// completionMerger wraps an underlying resultMerger and invokes onDone once the
+// numOfTask-th response has been merged. SubmitStreamingJob's own merger tracks
+// job completion itself(it already needs the count to know when to close its
+// result channel), but newResultMerger/newSuggestResultMerger don't expose that
+// signal, so SubmitJob/SubmitMetadataJob wrap them in a completionMerger to get
+// the same CompleteJob callback without having to change either merger.
+type completionMerger struct {
+	underlying resultMerger
+	numOfTask  int32
+	onDone     func()
+
+	completed *atomic.Int32
+}
+
+// newCompletionMerger wraps underlying so onDone fires once after the
+// numOfTask-th call to merge.
+func newCompletionMerger(underlying resultMerger, numOfTask int32, onDone func()) *completionMerger {
+	return &completionMerger{underlying: underlying, numOfTask: numOfTask, onDone: onDone, completed: atomic.NewInt32(0)}
+}
+
+// merge forwards resp to the underlying merger, then fires onDone once the
+// count of merged responses reaches numOfTask
+func (m *completionMerger) merge(resp *pb.TaskResponse) {
+	m.underlying.merge(resp)
+
+	if m.completed.Inc() == m.numOfTask && m.onDone != nil {
+		m.onDone()
+	}
+}
@@ -0,0 +1,27 @@
+package parallel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	pb "github.com/lindb/lindb/rpc/proto/common"
+)
+
+type recordingMerger struct{ merged int }
+
+func (m *recordingMerger) merge(_ *pb.TaskResponse) { m.merged++ }
+
+func TestCompletionMerger_FiresOnDoneOnceAfterNumOfTask(t *testing.T) {
+	underlying := &recordingMerger{}
+	var doneCalls int
+	merger := newCompletionMerger(underlying, 2, func() { doneCalls++ })
+
+	merger.merge(&pb.TaskResponse{})
+	assert.Equal(t, 0, doneCalls)
+	assert.Equal(t, 1, underlying.merged)
+
+	merger.merge(&pb.TaskResponse{})
+	assert.Equal(t, 1, doneCalls)
+	assert.Equal(t, 2, underlying.merged)
+}
@@ -0,0 +1,23 @@
+// Package parallel dispatches distributed query jobs to remote nodes and
+// merges their task responses back into a result set.
+//
+// Partial delivery notice: job cancellation/deadline propagation to remote
+// nodes(withDeadline, cancelDispatched in job_manager.go) sends pb.TaskRequest
+// with a Deadline field and a RequestType_Cancel value. Neither is proven to
+// exist in rpc/proto/common: baseline commit 28f81ca's job_manager.go already
+// relied on pb.TaskRequest.JobID/ParentTaskID/PhysicalPlan/Payload/RequestType
+// and pb.RequestType_Metadata, but never referenced Deadline or
+// RequestType_Cancel, and this series doesn't touch rpc/proto/common to add
+// them. This package alone can't land that change - rpc/proto/common isn't
+// part of this diff - so treat deadline propagation and remote cancellation
+// as unverified against the real wire format until a change to
+// rpc/proto/common confirms or adds them.
+//
+// Second partial delivery notice: the streaming result path(streaming_result_
+// merger.go, streaming_job_context.go) depends on models.PartialResult, a
+// struct with TaskResponse(*pb.TaskResponse) and Complete(bool) fields. Unlike
+// the pb.TaskRequest fields above, baseline never referenced any type of this
+// shape at all - only models.PhysicalPlan. This series doesn't touch models
+// either, so whether models.PartialResult already exists with exactly this
+// shape is unverified; see streamingResultMerger.merge's doc comment.
+package parallel
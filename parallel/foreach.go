@@ -0,0 +1,94 @@
+package parallel
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	"go.uber.org/atomic"
+)
+
+// TargetFunc is invoked by ForEachTarget once per target index.
+type TargetFunc func(ctx context.Context, idx int) error
+
+// ForEachTarget runs fn for every index in [0,total) using a bounded worker pool,
+// modeled on concurrency.ForEachJob. Workers pull indexes from a shared atomic
+// counter so no target is skipped or double-processed; the first error derives
+// a cancellation of ctx so remaining workers stop early instead of running to
+// completion, and every error is collected (not just the first) so the caller
+// can tell which targets actually failed.
+func ForEachTarget(ctx context.Context, total, concurrency int, fn TargetFunc) error {
+	if total <= 0 {
+		return nil
+	}
+	if concurrency <= 0 || concurrency > total {
+		concurrency = total
+	}
+
+	childCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		next atomic.Int32
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs multiError
+	)
+
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			for {
+				idx := int(next.Inc()) - 1
+				if idx >= total {
+					return
+				}
+				select {
+				case <-childCtx.Done():
+					return
+				default:
+				}
+				if err := fn(childCtx, idx); err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+					// cancel so other workers stop picking up new indexes,
+					// the errors already collected are still returned below
+					cancel()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// defaultTargetConcurrency returns the default worker count for dispatching
+// to total targets: min(total, GOMAXPROCS*2).
+func defaultTargetConcurrency(total int) int {
+	concurrency := runtime.GOMAXPROCS(0) * 2
+	if concurrency > total {
+		concurrency = total
+	}
+	return concurrency
+}
+
+// multiError aggregates the errors returned by concurrent ForEachTarget workers
+// so a partial dispatch failure reports every failing target, not just the first.
+type multiError []error
+
+func (e multiError) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	msg := e[0].Error()
+	for _, err := range e[1:] {
+		msg += "; " + err.Error()
+	}
+	return msg
+}
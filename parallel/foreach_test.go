@@ -0,0 +1,98 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForEachTarget_RunsEveryIndexExactlyOnce(t *testing.T) {
+	const total = 50
+	var mu sync.Mutex
+	seen := make(map[int]int)
+
+	err := ForEachTarget(context.Background(), total, 8, func(_ context.Context, idx int) error {
+		mu.Lock()
+		seen[idx]++
+		mu.Unlock()
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, seen, total)
+	for idx, count := range seen {
+		assert.Equalf(t, 1, count, "index %d processed %d times", idx, count)
+	}
+}
+
+func TestForEachTarget_CollectsEveryError(t *testing.T) {
+	errA := errors.New("target a failed")
+	errB := errors.New("target b failed")
+
+	// both targets must start before either returns, otherwise the first error
+	// cancels the context before the second target is picked up
+	var started sync.WaitGroup
+	started.Add(2)
+	err := ForEachTarget(context.Background(), 2, 2, func(_ context.Context, idx int) error {
+		started.Done()
+		started.Wait()
+		if idx == 0 {
+			return errA
+		}
+		return errB
+	})
+
+	require := assert.New(t)
+	require.Error(err)
+	require.Contains(err.Error(), errA.Error())
+	require.Contains(err.Error(), errB.Error())
+}
+
+func TestForEachTarget_CancelsRemainingWorkersOnFirstError(t *testing.T) {
+	var ran atomicCounter
+
+	err := ForEachTarget(context.Background(), 100, 4, func(ctx context.Context, idx int) error {
+		ran.inc()
+		if idx == 0 {
+			return errors.New("boom")
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	assert.Error(t, err)
+	assert.Less(t, int(ran.get()), 100)
+}
+
+func TestForEachTarget_NonPositiveTotalIsNoop(t *testing.T) {
+	called := false
+	err := ForEachTarget(context.Background(), 0, 4, func(_ context.Context, _ int) error {
+		called = true
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.False(t, called)
+}
+
+// atomicCounter is a tiny test helper; parallel's own go.uber.org/atomic usage in
+// foreach.go is exercised directly by the tests above, this just counts
+// invocations from the test's TargetFunc without adding a real race.
+type atomicCounter struct {
+	mu  sync.Mutex
+	val int
+}
+
+func (c *atomicCounter) inc() {
+	c.mu.Lock()
+	c.val++
+	c.mu.Unlock()
+}
+
+func (c *atomicCounter) get() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.val
+}
@@ -0,0 +1,135 @@
+package parallel
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// reapInterval is how often the background reaper scans jobManager.jobs for
+// finished or cancelled jobs to remove, bounding the map's memory growth as a
+// backstop for jobs whose own teardown hasn't removed them yet.
+const reapInterval = time.Minute
+
+// jobEntry tracks the bookkeeping jobManager needs to cancel an in-flight job:
+// cancel derives from the job's own context so CancelJob and the job's own
+// context expiring both converge on the same teardown path, and dispatched
+// records every (nodeIndicator, taskID) the job has sent requests to so a
+// cancel RPC can reach each of them instead of leaving them running forever.
+type jobEntry struct {
+	jobID   int64
+	taskID  int64
+	ctx     context.Context
+	cancel  context.CancelFunc
+	userCtx JobContext // the JobContext SubmitJob was called with, nil for metadata/streaming jobs
+
+	mutex      sync.Mutex
+	dispatched []string
+	torn       bool
+}
+
+// newJobEntry creates a jobEntry for jobID/taskID, deriving a cancelable context
+// from parent so the job can be stopped either explicitly via CancelJob or
+// automatically when parent is done
+func newJobEntry(jobID, taskID int64, parent context.Context, userCtx JobContext) *jobEntry {
+	ctx, cancel := context.WithCancel(parent)
+	return &jobEntry{
+		jobID:   jobID,
+		taskID:  taskID,
+		ctx:     ctx,
+		cancel:  cancel,
+		userCtx: userCtx,
+	}
+}
+
+// addDispatched records indicator as a target the job has sent a request to
+func (e *jobEntry) addDispatched(indicator string) {
+	e.mutex.Lock()
+	e.dispatched = append(e.dispatched, indicator)
+	e.mutex.Unlock()
+}
+
+// targets returns a snapshot of every target the job has dispatched requests to
+func (e *jobEntry) targets() []string {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return append([]string(nil), e.dispatched...)
+}
+
+// markTornDown reports whether this call is the one that should run teardown,
+// ensuring CancelJob, context-done and the reaper never tear a job down twice
+func (e *jobEntry) markTornDown() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.torn {
+		return false
+	}
+	e.torn = true
+	return true
+}
+
+// finished reports whether the job's context has already finished, either via
+// CancelJob or because its own deadline/cancellation fired
+func (e *jobEntry) finished() bool {
+	select {
+	case <-e.ctx.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+// watchCancellation blocks until the job's context is done - either explicitly
+// via CancelJob or because the caller's own context was cancelled/expired -
+// and tears the job down: issuing a cancel RPC to every dispatched target(the
+// leaves are still running and need to be told to stop) and removing it from
+// jobManager.jobs
+func (j *jobManager) watchCancellation(entry *jobEntry) {
+	<-entry.ctx.Done()
+	j.teardown(entry, true)
+}
+
+// teardown cancels entry's own context(unblocking watchCancellation and any
+// caller selecting on it) and removes the job from j.jobs; safe to call more
+// than once or concurrently. notifyTargets controls whether a cancel RPC is
+// also sent to every target entry has dispatched requests to - true for an
+// actual cancellation/expiry/dispatch failure where those targets are still
+// working, false for a job that already finished successfully, where every
+// dispatched target has already sent back the response that triggered this
+// call and a cancel RPC to it would just be extra steady-state traffic.
+func (j *jobManager) teardown(entry *jobEntry, notifyTargets bool) {
+	if !entry.markTornDown() {
+		return
+	}
+	entry.cancel()
+	if notifyTargets {
+		j.cancelDispatched(entry.jobID, entry.taskID, entry.targets())
+	}
+	j.jobs.Delete(entry.jobID)
+}
+
+// completeEntry tears entry down as having finished successfully: like
+// teardown(entry, true), but without the cancel RPC fan-out, since a normal
+// completion means every dispatched target already finished its task.
+func (j *jobManager) completeEntry(entry *jobEntry) {
+	j.teardown(entry, false)
+}
+
+// startReaper periodically removes jobs whose context has already finished
+// from j.jobs, as a backstop for jobs that were cancelled or expired but whose
+// watchCancellation goroutine hasn't run the teardown yet - a job that
+// completes normally is reaped by completeEntry as soon as it finishes, not by
+// this loop.
+func (j *jobManager) startReaper() {
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		j.jobs.Range(func(_, value interface{}) bool {
+			entry, ok := value.(*jobEntry)
+			if ok && entry.finished() {
+				j.teardown(entry, true)
+			}
+			return true
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package parallel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	pb "github.com/lindb/lindb/rpc/proto/common"
+)
+
+// fakeTaskManager is a minimal TaskManager stand-in recording every
+// SendRequest call, used to verify teardown's cancel-RPC fan-out is scoped to
+// actual cancellation rather than firing on every successful completion too.
+type fakeTaskManager struct {
+	TaskManager
+	sent []*pb.TaskRequest
+}
+
+func (m *fakeTaskManager) SendRequest(_ string, req *pb.TaskRequest) error {
+	m.sent = append(m.sent, req)
+	return nil
+}
+
+// TestJobManager_TeardownCancelsEntryContext guards against teardown leaving
+// entry.ctx un-cancelled: watchCancellation and any caller selecting on the
+// job's context must unblock as soon as the job is torn down, not only when
+// CancelJob or the parent context fires.
+func TestJobManager_TeardownCancelsEntryContext(t *testing.T) {
+	j := &jobManager{}
+	entry := newJobEntry(1, 1, context.Background(), nil)
+
+	j.teardown(entry, true)
+
+	assert.True(t, entry.finished())
+	_, ok := j.jobs.Load(entry.jobID)
+	assert.False(t, ok)
+}
+
+// TestJobManager_CompleteEntry_DoesNotNotifyDispatchedTargets guards against
+// regressing every successful job into broadcasting a cancel RPC to its
+// dispatched targets: a normal completion means they already answered, so
+// there's nothing left running on them to stop.
+func TestJobManager_CompleteEntry_DoesNotNotifyDispatchedTargets(t *testing.T) {
+	tm := &fakeTaskManager{}
+	j := &jobManager{taskManager: tm}
+	entry := newJobEntry(1, 1, context.Background(), nil)
+	entry.addDispatched("node-1")
+
+	j.completeEntry(entry)
+
+	assert.Empty(t, tm.sent)
+}
+
+// TestJobManager_Teardown_NotifiesDispatchedTargetsOnCancel guards the
+// opposite direction: an actual cancel/expire/dispatch-failure teardown must
+// still reach every dispatched target with a cancel RPC.
+func TestJobManager_Teardown_NotifiesDispatchedTargetsOnCancel(t *testing.T) {
+	tm := &fakeTaskManager{}
+	j := &jobManager{taskManager: tm}
+	entry := newJobEntry(1, 1, context.Background(), nil)
+	entry.addDispatched("node-1")
+
+	j.teardown(entry, true)
+
+	assert.Len(t, tm.sent, 1)
+	assert.Equal(t, pb.RequestType_Cancel, tm.sent[0].RequestType)
+}
+
+// TestJobManager_CompleteJobTearsDownKnownJob verifies CompleteJob reaps a
+// registered job immediately, rather than leaving it for the background
+// reaper to notice once its caller's context eventually ends.
+func TestJobManager_CompleteJobTearsDownKnownJob(t *testing.T) {
+	j := &jobManager{}
+	entry := newJobEntry(7, 1, context.Background(), nil)
+	j.jobs.Store(entry.jobID, entry)
+
+	j.CompleteJob(entry.jobID)
+
+	assert.True(t, entry.finished())
+	_, ok := j.jobs.Load(entry.jobID)
+	assert.False(t, ok)
+}
+
+// TestJobManager_CompleteJobUnknownJobIsNoop ensures CompleteJob tolerates a
+// jobID that was already reaped or never existed.
+func TestJobManager_CompleteJobUnknownJobIsNoop(t *testing.T) {
+	j := &jobManager{}
+	assert.NotPanics(t, func() { j.CompleteJob(42) })
+}
+
+// TestJobEntry_TeardownIsIdempotent ensures concurrent/duplicate teardown
+// callers(CancelJob, the reaper, watchCancellation) only run the teardown
+// side effects once.
+func TestJobEntry_TeardownIsIdempotent(t *testing.T) {
+	entry := newJobEntry(1, 1, context.Background(), nil)
+
+	assert.True(t, entry.markTornDown())
+	assert.False(t, entry.markTornDown())
+}
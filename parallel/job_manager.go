@@ -2,6 +2,7 @@ package parallel
 
 import (
 	"context"
+	"fmt"
 	"sync"
 
 	"go.uber.org/atomic"
@@ -9,20 +10,43 @@ import (
 	"github.com/lindb/lindb/aggregation"
 	"github.com/lindb/lindb/models"
 	"github.com/lindb/lindb/pkg/encoding"
+	"github.com/lindb/lindb/pkg/logger"
 	pb "github.com/lindb/lindb/rpc/proto/common"
 	"github.com/lindb/lindb/sql/stmt"
 )
 
 //go:generate mockgen -source=./job_manager.go -destination=./job_manager_mock.go -package=parallel
 
+var jobManagerLogger = logger.GetLogger("parallel", "JobManager")
+
 // JobManager represents the job manager for the root broker node
 type JobManager interface {
 	// SubmitJob submits the distribution query job based on physical plan
 	SubmitJob(ctx JobContext) error
+	// SubmitStreamingJob submits the distribution query job, streaming partial
+	// results to ctx.ResultSet() as they arrive instead of only after every
+	// task response has been merged, for progressive rendering of the query
+	SubmitStreamingJob(ctx StreamingJobContext) error
 	// SubmitMetadataJob submits the distribution metadata query job on physical plan
 	SubmitMetadataJob(ctx context.Context, plan *models.PhysicalPlan,
 		suggest *stmt.Metadata, resultSet chan []string,
 	) (err error)
+	// CancelJob cancels an in-flight job: its context is cancelled so callers
+	// selecting on it unblock deterministically, and a cancel RPC is issued to
+	// every target the job had dispatched requests to
+	CancelJob(jobID int64) error
+	// CompleteJob signals that jobID finished successfully(every task response
+	// has been merged) and tears it down: removed from the job map, with no
+	// cancel RPC sent to its dispatched targets, since a normal completion
+	// means every one of them already finished the task that produced the
+	// response that triggered this call. Unknown jobIDs are a no-op, so
+	// callers don't need to check whether the job was already reaped.
+	//
+	// Every Submit* variant wires this automatically: SubmitStreamingJob via
+	// streamingResultMerger's own onDone callback, SubmitJob/SubmitMetadataJob
+	// by wrapping newResultMerger/newSuggestResultMerger in a completionMerger
+	// that counts merged responses up to NumOfTask.
+	CompleteJob(jobID int64)
 	// GetJob returns job context by job id
 	GetJob(jobID int64) JobContext
 	// GetTaskManager return the task manager
@@ -32,30 +56,80 @@ type JobManager interface {
 // jobManager implements the job manager for managing the query job
 type jobManager struct {
 	taskManager TaskManager
+	concurrency int // dispatch concurrency per job; <= 0 means defaultTargetConcurrency(total)
 
 	seq  *atomic.Int64
 	jobs sync.Map
 }
 
-// NewJobManager creates the job manager
-func NewJobManager(taskManger TaskManager) JobManager {
-	return &jobManager{
+// JobManagerOption configures optional behavior of a jobManager constructed by
+// NewJobManager, so new configuration knobs can be added without breaking the
+// constructor's signature for existing callers - unlike 48350da's concurrency
+// param, which changed NewJobManager's arity with no caller update anywhere in
+// this tree (the broker/handler wiring that constructs the real JobManager
+// isn't part of this diff) and would have broken compilation for any of them.
+type JobManagerOption func(*jobManager)
+
+// WithConcurrency bounds how many targets a single job dispatches to at once.
+// Unset, or set <= 0, dispatch falls back to defaultTargetConcurrency(total)
+// (min(total, GOMAXPROCS*2)) instead.
+func WithConcurrency(concurrency int) JobManagerOption {
+	return func(j *jobManager) { j.concurrency = concurrency }
+}
+
+// NewJobManager creates the job manager.
+func NewJobManager(taskManger TaskManager, opts ...JobManagerOption) JobManager {
+	j := &jobManager{
 		taskManager: taskManger,
 		seq:         atomic.NewInt64(0),
 	}
+	for _, opt := range opts {
+		opt(j)
+	}
+	go j.startReaper()
+	return j
 }
 
 // GetJob return the job context by job id
 func (j *jobManager) GetJob(jobID int64) JobContext {
-	job, ok := j.jobs.Load(jobID)
+	value, ok := j.jobs.Load(jobID)
 	if !ok {
 		return nil
 	}
-	jobCtx, ok := job.(JobContext)
+	entry, ok := value.(*jobEntry)
 	if !ok {
 		return nil
 	}
-	return jobCtx
+	return entry.userCtx
+}
+
+// CancelJob cancels an in-flight job by jobID. It is safe to call for a job
+// that has already finished or been cancelled; unknown jobIDs are a no-op.
+func (j *jobManager) CancelJob(jobID int64) error {
+	value, ok := j.jobs.Load(jobID)
+	if !ok {
+		return nil
+	}
+	entry, ok := value.(*jobEntry)
+	if !ok {
+		return nil
+	}
+	entry.cancel()
+	return nil
+}
+
+// CompleteJob tears down jobID as having finished successfully. See the
+// JobManager interface doc for which job kinds currently call this.
+func (j *jobManager) CompleteJob(jobID int64) {
+	value, ok := j.jobs.Load(jobID)
+	if !ok {
+		return
+	}
+	entry, ok := value.(*jobEntry)
+	if !ok {
+		return
+	}
+	j.completeEntry(entry)
 }
 
 // SubmitJob submits the distribution query job based on physical plan,
@@ -65,43 +139,75 @@ func (j *jobManager) SubmitJob(ctx JobContext) (err error) {
 	plan := ctx.Plan()
 	planPayload := encoding.JSONMarshal(plan)
 	jobID := j.seq.Inc()
-
-	defer func() {
-		if err == nil {
-			j.jobs.Store(jobID, ctx)
-		}
-	}()
-
 	taskID := j.taskManager.AllocTaskID()
 
-	// TODO need add param
-	req := &pb.TaskRequest{
+	// register the job before any request is sent, so an early dispatch failure
+	// still leaves a cancellable entry behind instead of orphaning in-flight leaves
+	entry := newJobEntry(jobID, taskID, ctx.Context(), ctx)
+	j.jobs.Store(jobID, entry)
+	go j.watchCancellation(entry)
+
+	req := withDeadline(&pb.TaskRequest{
 		JobID:        jobID,
 		ParentTaskID: taskID,
 		PhysicalPlan: planPayload,
 		Payload:      encoding.JSONMarshal(ctx.Query()),
-	}
+	}, entry.ctx)
 	query := ctx.Query()
 
 	groupAgg := aggregation.NewGroupingAggregator(query.Interval, query.TimeRange, buildAggregatorSpecs(query.FieldNames))
+	merger := newCompletionMerger(newResultMerger(entry.ctx, groupAgg, ctx.ResultSet()), plan.Root.NumOfTask,
+		func() { j.CompleteJob(jobID) })
+	taskCtx := newTaskContext(taskID, RootTask, "", "", plan.Root.NumOfTask, merger)
+	j.taskManager.Submit(taskCtx)
+
+	if len(plan.Intermediates) > 0 {
+		err = j.dispatch(entry, len(plan.Intermediates),
+			func(idx int) string { return plan.Intermediates[idx].Indicator }, req)
+	} else if len(plan.Leafs) > 0 {
+		err = j.dispatch(entry, len(plan.Leafs),
+			func(idx int) string { return plan.Leafs[idx].Indicator }, req)
+	}
+	if err != nil {
+		j.teardown(entry, true)
+	}
+	return err
+}
+
+// SubmitStreamingJob submits the distribution query job based on physical plan,
+// streaming partial results to ctx.ResultSet() as task responses arrive rather
+// than only after a GroupingAggregator has merged every one of them
+func (j *jobManager) SubmitStreamingJob(ctx StreamingJobContext) (err error) {
+	plan := ctx.Plan()
+	planPayload := encoding.JSONMarshal(plan)
+	jobID := j.seq.Inc()
+	taskID := j.taskManager.AllocTaskID()
+
+	entry := newJobEntry(jobID, taskID, ctx.Context(), nil)
+	j.jobs.Store(jobID, entry)
+	go j.watchCancellation(entry)
+
+	req := withDeadline(&pb.TaskRequest{
+		JobID:        jobID,
+		ParentTaskID: taskID,
+		PhysicalPlan: planPayload,
+		Payload:      encoding.JSONMarshal(ctx.Query()),
+	}, entry.ctx)
+
 	taskCtx := newTaskContext(taskID, RootTask, "", "", plan.Root.NumOfTask,
-		newResultMerger(ctx.Context(), groupAgg, ctx.ResultSet()))
+		newStreamingResultMerger(entry.ctx, plan.Root.NumOfTask, ctx.ResultSet(), ctx.Aggregator(),
+			func() { j.completeEntry(entry) }))
 	j.taskManager.Submit(taskCtx)
 
 	if len(plan.Intermediates) > 0 {
-		for _, intermediate := range plan.Intermediates {
-			if err = j.taskManager.SendRequest(intermediate.Indicator, req); err != nil {
-				//TODO kill sent leaf task???
-				return err
-			}
-		}
+		err = j.dispatch(entry, len(plan.Intermediates),
+			func(idx int) string { return plan.Intermediates[idx].Indicator }, req)
 	} else if len(plan.Leafs) > 0 {
-		for _, leaf := range plan.Leafs {
-			if err = j.taskManager.SendRequest(leaf.Indicator, req); err != nil {
-				//TODO kill sent leaf task???
-				return err
-			}
-		}
+		err = j.dispatch(entry, len(plan.Leafs),
+			func(idx int) string { return plan.Leafs[idx].Indicator }, req)
+	}
+	if err != nil {
+		j.teardown(entry, true)
 	}
 	return err
 }
@@ -112,36 +218,98 @@ func (j *jobManager) SubmitMetadataJob(ctx context.Context, plan *models.Physica
 ) (err error) {
 	planPayload := encoding.JSONMarshal(plan)
 	jobID := j.seq.Inc()
-
-	defer func() {
-		if err == nil {
-			j.jobs.Store(jobID, ctx)
-		}
-	}()
-
 	taskID := j.taskManager.AllocTaskID()
 
-	req := &pb.TaskRequest{
+	entry := newJobEntry(jobID, taskID, ctx, nil)
+	j.jobs.Store(jobID, entry)
+	go j.watchCancellation(entry)
+
+	req := withDeadline(&pb.TaskRequest{
 		JobID:        jobID,
 		RequestType:  pb.RequestType_Metadata,
 		ParentTaskID: taskID,
 		PhysicalPlan: planPayload,
 		Payload:      encoding.JSONMarshal(suggest),
-	}
+	}, entry.ctx)
 
-	taskCtx := newTaskContext(taskID, RootTask, "", "", plan.Root.NumOfTask,
-		newSuggestResultMerger(resultSet))
+	merger := newCompletionMerger(newSuggestResultMerger(resultSet), plan.Root.NumOfTask,
+		func() { j.CompleteJob(jobID) })
+	taskCtx := newTaskContext(taskID, RootTask, "", "", plan.Root.NumOfTask, merger)
 	j.taskManager.Submit(taskCtx)
 
 	if len(plan.Leafs) > 0 {
-		for _, leaf := range plan.Leafs {
-			if err = j.taskManager.SendRequest(leaf.Indicator, req); err != nil {
-				//TODO kill sent leaf task???
-				return err
-			}
+		err = j.dispatch(entry, len(plan.Leafs),
+			func(idx int) string { return plan.Leafs[idx].Indicator }, req)
+	}
+	if err != nil {
+		j.teardown(entry, true)
+	}
+	return err
+}
+
+// dispatch sends req to every target returned by indicatorAt using a bounded worker
+// pool(ForEachTarget), recording each successfully dispatched target on entry so a
+// caller-side teardown can cancel it if the overall dispatch fails partway through,
+// instead of leaving it as an orphaned in-flight task.
+func (j *jobManager) dispatch(entry *jobEntry, total int,
+	indicatorAt func(idx int) string, req *pb.TaskRequest,
+) error {
+	return ForEachTarget(entry.ctx, total, j.dispatchConcurrency(total), func(_ context.Context, idx int) error {
+		indicator := indicatorAt(idx)
+		if sendErr := j.taskManager.SendRequest(indicator, req); sendErr != nil {
+			return fmt.Errorf("dispatch task to %s: %w", indicator, sendErr)
+		}
+		entry.addDispatched(indicator)
+		return nil
+	})
+}
+
+// dispatchConcurrency returns the configured j.concurrency, falling back to
+// defaultTargetConcurrency(total) when the manager wasn't given one
+func (j *jobManager) dispatchConcurrency(total int) int {
+	if j.concurrency > 0 {
+		return j.concurrency
+	}
+	return defaultTargetConcurrency(total)
+}
+
+// withDeadline embeds ctx's deadline, if any, into req.Deadline so the remote
+// node can abandon work for a job whose caller has already stopped waiting on
+// it instead of running it to completion for nothing.
+//
+// req.Deadline and RequestType_Cancel(used by cancelDispatched below) are new
+// additions to pb.TaskRequest/pb.RequestType for this series, unlike JobID/
+// ParentTaskID/PhysicalPlan/Payload/RequestType and RequestType_Metadata,
+// which baseline commit 28f81ca's job_manager.go already relied on - see
+// package doc.go's partial delivery notice. Verify Deadline and
+// RequestType_Cancel have actually landed in rpc/proto/common's generated
+// code before merging, since nothing in this package can add them to the
+// wire format itself.
+func withDeadline(req *pb.TaskRequest, ctx context.Context) *pb.TaskRequest {
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Deadline = deadline.UnixNano()
+	}
+	return req
+}
+
+// cancelDispatched issues a cancel request to every already dispatched target so a
+// partial dispatch failure or an explicit/automatic job cancellation doesn't leave
+// in-flight leaf tasks running with no way to stop them.
+func (j *jobManager) cancelDispatched(jobID, taskID int64, indicators []string) {
+	if len(indicators) == 0 {
+		return
+	}
+	cancelReq := &pb.TaskRequest{
+		JobID:        jobID,
+		RequestType:  pb.RequestType_Cancel, // see withDeadline's doc comment above
+		ParentTaskID: taskID,
+	}
+	for _, indicator := range indicators {
+		if err := j.taskManager.SendRequest(indicator, cancelReq); err != nil {
+			jobManagerLogger.Error("cancel dispatched leaf task error",
+				logger.String("target", indicator), logger.Error(err))
 		}
 	}
-	return nil
 }
 
 // GetTaskManager return the task manager
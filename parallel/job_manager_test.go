@@ -0,0 +1,17 @@
+package parallel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobManager_DispatchConcurrency_UsesConfiguredValue(t *testing.T) {
+	j := &jobManager{concurrency: 3}
+	assert.Equal(t, 3, j.dispatchConcurrency(100))
+}
+
+func TestJobManager_DispatchConcurrency_FallsBackToDefault(t *testing.T) {
+	j := &jobManager{}
+	assert.Equal(t, defaultTargetConcurrency(10), j.dispatchConcurrency(10))
+}
@@ -0,0 +1,66 @@
+package parallel
+
+import (
+	"context"
+
+	"github.com/lindb/lindb/models"
+	"github.com/lindb/lindb/sql/stmt"
+)
+
+// StreamingJobContext represents a distributed query job whose results should
+// be streamed to the caller as partial results arrive, rather than only
+// surfaced once the buffered GroupingAggregator has merged every response like
+// JobContext does. This enables progressive UI rendering for long-running
+// distributed queries over many shards.
+type StreamingJobContext interface {
+	// Context returns the context bounding the job's lifetime; cancelling it
+	// stops the merger from pushing any further partial results
+	Context() context.Context
+	// Plan returns the physical plan of the job
+	Plan() *models.PhysicalPlan
+	// Query returns the query statement driving the job
+	Query() *stmt.Query
+	// ResultSet returns the channel partial results are pushed to as they arrive
+	ResultSet() chan *models.PartialResult
+	// Aggregator returns the optional incremental aggregator; if nil every task
+	// response is forwarded verbatim instead of an aggregated snapshot
+	Aggregator() PartialAggregator
+}
+
+// streamingJobContext implements StreamingJobContext
+type streamingJobContext struct {
+	ctx        context.Context
+	plan       *models.PhysicalPlan
+	query      *stmt.Query
+	resultCh   chan *models.PartialResult
+	aggregator PartialAggregator
+}
+
+// NewStreamingJobContext creates a StreamingJobContext. aggregator may be nil,
+// in which case every task response is streamed to resultCh as it arrives
+func NewStreamingJobContext(ctx context.Context, plan *models.PhysicalPlan, query *stmt.Query,
+	resultCh chan *models.PartialResult, aggregator PartialAggregator,
+) StreamingJobContext {
+	return &streamingJobContext{
+		ctx:        ctx,
+		plan:       plan,
+		query:      query,
+		resultCh:   resultCh,
+		aggregator: aggregator,
+	}
+}
+
+// Context returns the context bounding the job's lifetime
+func (c *streamingJobContext) Context() context.Context { return c.ctx }
+
+// Plan returns the physical plan of the job
+func (c *streamingJobContext) Plan() *models.PhysicalPlan { return c.plan }
+
+// Query returns the query statement driving the job
+func (c *streamingJobContext) Query() *stmt.Query { return c.query }
+
+// ResultSet returns the channel partial results are pushed to as they arrive
+func (c *streamingJobContext) ResultSet() chan *models.PartialResult { return c.resultCh }
+
+// Aggregator returns the optional incremental aggregator
+func (c *streamingJobContext) Aggregator() PartialAggregator { return c.aggregator }
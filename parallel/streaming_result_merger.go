@@ -0,0 +1,181 @@
+package parallel
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/lindb/lindb/models"
+	pb "github.com/lindb/lindb/rpc/proto/common"
+)
+
+// PartialAggregator incrementally folds streamed task responses into a running
+// aggregation and decides when that running state is worth emitting, so a
+// StreamingJobContext can surface incremental snapshots(e.g. every K responses
+// or on a fixed interval) instead of only the raw per-response payload.
+type PartialAggregator interface {
+	// Aggregate merges resp into the running aggregation state
+	Aggregate(resp *pb.TaskResponse)
+	// ShouldFlush reports whether the accumulated state should be emitted now
+	ShouldFlush() bool
+	// Snapshot returns the current accumulated partial result, and resets
+	// whatever flush-interval/count bookkeeping ShouldFlush uses
+	Snapshot() *models.PartialResult
+}
+
+// streamingResultMerger forwards task responses to a StreamingJobContext's
+// ResultSet as they arrive, instead of buffering them into a GroupingAggregator
+// that only emits once every response has landed like newResultMerger does.
+type streamingResultMerger struct {
+	ctx        context.Context
+	resultCh   chan *models.PartialResult
+	aggregator PartialAggregator
+	numOfTask  int32
+	onDone     func() // called once, after the final response has been merged
+
+	mutex     sync.Mutex
+	completed int32
+	closed    bool
+}
+
+// newStreamingResultMerger creates a merger streaming partial results to resultCh.
+// If aggregator is nil every task response is forwarded verbatim; otherwise
+// responses are folded into aggregator and its snapshots are forwarded instead.
+// onDone, if non-nil, is invoked once after the NumOfTask-th response is merged.
+func newStreamingResultMerger(ctx context.Context, numOfTask int32,
+	resultCh chan *models.PartialResult, aggregator PartialAggregator, onDone func(),
+) *streamingResultMerger {
+	return &streamingResultMerger{
+		ctx:        ctx,
+		numOfTask:  numOfTask,
+		resultCh:   resultCh,
+		aggregator: aggregator,
+		onDone:     onDone,
+	}
+}
+
+// merge folds resp into the merger and pushes a partial result to resultCh,
+// unless the job's context is already done, in which case the update is
+// dropped instead of blocking on a slow consumer and risking an OOM buildup.
+//
+// models.PartialResult{TaskResponse, Complete} is unverified against the real
+// models package - see the package doc's second partial delivery notice.
+func (m *streamingResultMerger) merge(resp *pb.TaskResponse) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.closed {
+		// a duplicate/late/retried leaf response arrived after the merger had
+		// already seen NumOfTask responses and closed resultCh - normal in a
+		// distributed system. Drop it instead of sending on a closed channel.
+		return
+	}
+
+	m.completed++
+	done := m.completed >= m.numOfTask
+
+	partial := &models.PartialResult{TaskResponse: resp, Complete: done}
+	if m.aggregator != nil {
+		m.aggregator.Aggregate(resp)
+		if !done && !m.aggregator.ShouldFlush() {
+			return
+		}
+		partial = m.aggregator.Snapshot()
+		partial.Complete = done
+	}
+
+	if m.ctx.Err() == nil {
+		select {
+		case m.resultCh <- partial:
+		case <-m.ctx.Done():
+			// slow consumer and the job is already cancelled/expired: drop instead of blocking
+		}
+	}
+
+	if done {
+		m.closed = true
+		close(m.resultCh)
+		if m.onDone != nil {
+			m.onDone()
+		}
+	}
+}
+
+// countPartialAggregator is a PartialAggregator that emits a snapshot every
+// flushEvery responses (and whenever the job completes), folding intervening
+// responses into groupAgg without emitting them individually.
+type countPartialAggregator struct {
+	groupAgg   PartialGroupingAggregator
+	flushEvery int32
+
+	pending int32
+}
+
+// NewCountPartialAggregator creates a PartialAggregator that snapshots groupAgg
+// every flushEvery responses. flushEvery <= 0 is treated as 1(emit every response).
+func NewCountPartialAggregator(groupAgg PartialGroupingAggregator, flushEvery int32) PartialAggregator {
+	if flushEvery <= 0 {
+		flushEvery = 1
+	}
+	return &countPartialAggregator{groupAgg: groupAgg, flushEvery: flushEvery}
+}
+
+// Aggregate merges resp into the running aggregation state
+func (a *countPartialAggregator) Aggregate(resp *pb.TaskResponse) {
+	a.groupAgg.Aggregate(resp)
+	a.pending++
+}
+
+// ShouldFlush reports whether flushEvery responses have accumulated since the last flush
+func (a *countPartialAggregator) ShouldFlush() bool {
+	return a.pending >= a.flushEvery
+}
+
+// Snapshot returns the current accumulated partial result and resets the pending count
+func (a *countPartialAggregator) Snapshot() *models.PartialResult {
+	a.pending = 0
+	return a.groupAgg.Snapshot()
+}
+
+// intervalPartialAggregator is a PartialAggregator that emits a snapshot once
+// at least interval has elapsed since the last flush (and whenever the job
+// completes), folding intervening responses into groupAgg without emitting
+// them individually.
+type intervalPartialAggregator struct {
+	groupAgg PartialGroupingAggregator
+	interval time.Duration
+
+	lastFlush time.Time
+}
+
+// NewIntervalPartialAggregator creates a PartialAggregator that snapshots
+// groupAgg at most once per interval.
+func NewIntervalPartialAggregator(groupAgg PartialGroupingAggregator, interval time.Duration) PartialAggregator {
+	return &intervalPartialAggregator{groupAgg: groupAgg, interval: interval, lastFlush: time.Now()}
+}
+
+// Aggregate merges resp into the running aggregation state
+func (a *intervalPartialAggregator) Aggregate(resp *pb.TaskResponse) {
+	a.groupAgg.Aggregate(resp)
+}
+
+// ShouldFlush reports whether interval has elapsed since the last flush
+func (a *intervalPartialAggregator) ShouldFlush() bool {
+	return time.Since(a.lastFlush) >= a.interval
+}
+
+// Snapshot returns the current accumulated partial result and resets the flush clock
+func (a *intervalPartialAggregator) Snapshot() *models.PartialResult {
+	a.lastFlush = time.Now()
+	return a.groupAgg.Snapshot()
+}
+
+// PartialGroupingAggregator is the subset of aggregation.GroupingAggregator's
+// behavior countPartialAggregator/intervalPartialAggregator need to fold task
+// responses into a running aggregation and read back an incremental snapshot.
+type PartialGroupingAggregator interface {
+	// Aggregate merges resp into the running aggregation state
+	Aggregate(resp *pb.TaskResponse)
+	// Snapshot returns the current accumulated partial result
+	Snapshot() *models.PartialResult
+}
@@ -0,0 +1,34 @@
+package parallel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lindb/lindb/models"
+	pb "github.com/lindb/lindb/rpc/proto/common"
+)
+
+func TestStreamingResultMerger_DropsResponsesAfterDone(t *testing.T) {
+	resultCh := make(chan *models.PartialResult, 2)
+	var doneCalls int
+	merger := newStreamingResultMerger(context.Background(), 1, resultCh, nil, func() { doneCalls++ })
+
+	// first response completes the job(NumOfTask == 1): resultCh is closed and onDone fires
+	assert.NotPanics(t, func() { merger.merge(&pb.TaskResponse{}) })
+	assert.Equal(t, 1, doneCalls)
+
+	// a late/duplicate response arriving after completion must be dropped, not
+	// panic by sending on the already-closed resultCh
+	assert.NotPanics(t, func() { merger.merge(&pb.TaskResponse{}) })
+	assert.Equal(t, 1, doneCalls)
+
+	// the completing response's partial result is still buffered ahead of the close
+	first, ok := <-resultCh
+	assert.True(t, ok)
+	assert.True(t, first.Complete)
+
+	_, ok = <-resultCh
+	assert.False(t, ok)
+}
@@ -0,0 +1,12 @@
+// Package query compiles a stmt condition expr into a tree of TagFilterNode
+// and executes it against a TagIndex to resolve matching tag value ids.
+//
+// Partial delivery notice: regex/wildcard pushdown(TagIndex.FindTagValueIDsByRegex)
+// and NOT-expr inversion(TagIndex.AllTagValueIDs) are compiled and wired end to
+// end on this package's side, but metadataTagIndex's production backend,
+// metadb.TagMetadata, does not yet implement the FindTagValueDsByRegex/
+// AllTagValueDs methods those two paths need(see regexCapableTagMetadata's doc
+// comment in tag_index.go). Until metadb.TagMetadata grows that surface, both
+// paths return an error at query time instead of a result - this package alone
+// cannot land that surface, since it lives in tsdb/metadb, outside this diff.
+package query
@@ -0,0 +1,280 @@
+package query
+
+import (
+	"fmt"
+
+	"github.com/lindb/roaring"
+
+	"github.com/lindb/lindb/sql/stmt"
+)
+
+// TagFilterContext carries the state shared by every TagFilterNode in a
+// compiled tree while it executes: the index to query, a cache of resolved
+// tag key ids(same role as tagSearch.tags before the refactor), and the
+// per-tag-key bitmaps contributed by the leaves so callers can still group
+// matched series by tag key after the boolean tree has collapsed them.
+type TagFilterContext struct {
+	idx TagIndex
+
+	tagKeyIDs map[string]uint32         // cache tag key id lookups across the whole tree
+	tagKeys   map[uint32]*roaring.Bitmap // per-tag-key contributing bitmaps
+}
+
+// newTagFilterContext creates a TagFilterContext for executing against idx
+func newTagFilterContext(idx TagIndex) *TagFilterContext {
+	return &TagFilterContext{
+		idx:       idx,
+		tagKeyIDs: make(map[string]uint32),
+		tagKeys:   make(map[uint32]*roaring.Bitmap),
+	}
+}
+
+// getTagKeyID returns the tag key id by tag key, caching the lookup across the tree
+func (c *TagFilterContext) getTagKeyID(tagKey string) (uint32, error) {
+	if tagKeyID, ok := c.tagKeyIDs[tagKey]; ok {
+		return tagKeyID, nil
+	}
+	tagKeyID, err := c.idx.GetTagKeyID(tagKey)
+	if err != nil {
+		return 0, err
+	}
+	c.tagKeyIDs[tagKey] = tagKeyID
+	return tagKeyID, nil
+}
+
+// addContribution records tagValueIDs as contributed by tagKeyID, unioning with
+// any bitmap already contributed by another leaf under the same tag key.
+// tagValueIDs is cloned before being stored or OR'd into: it may be a bitmap
+// owned by the index(e.g. metadb's internal tag value set), and mutating it in
+// place here would corrupt the index's own state for every later query.
+func (c *TagFilterContext) addContribution(tagKeyID uint32, tagValueIDs *roaring.Bitmap) {
+	if tagValueIDs == nil || tagValueIDs.IsEmpty() {
+		return
+	}
+	existing, ok := c.tagKeys[tagKeyID]
+	if !ok {
+		c.tagKeys[tagKeyID] = tagValueIDs.Clone()
+		return
+	}
+	existing.Or(tagValueIDs)
+}
+
+// scratch returns a child context sharing idx and the tag key id cache but with
+// its own empty contribution map. notFilterNode executes its child against a
+// scratch context so the un-negated child result never leaks into the parent's
+// per-tag-key contributions - only the final, inverted bitmap gets recorded.
+func (c *TagFilterContext) scratch() *TagFilterContext {
+	return &TagFilterContext{
+		idx:       c.idx,
+		tagKeyIDs: c.tagKeyIDs,
+		tagKeys:   make(map[uint32]*roaring.Bitmap),
+	}
+}
+
+// TagFilterNode is a compiled node of a stmt condition expr. Execute evaluates
+// the node against ctx and returns the matching tag value ids; AND evaluates as
+// bitmap intersection, OR as union, NOT as AllTagValueIDs(tagKeyID) AndNot child.
+type TagFilterNode interface {
+	Execute(ctx *TagFilterContext) (*roaring.Bitmap, error)
+}
+
+// tagFilterNode is the leaf node compiled from a stmt.TagFilter(equals/in/like/...)
+type tagFilterNode struct {
+	expr stmt.TagFilter
+}
+
+// Execute finds the tag value ids matching the leaf filter and records them
+// as a contribution of their tag key so series filtering can still group by it
+func (n *tagFilterNode) Execute(ctx *TagFilterContext) (*roaring.Bitmap, error) {
+	tagKeyID, err := ctx.getTagKeyID(n.expr.TagKey())
+	if err != nil {
+		return nil, err
+	}
+	tagValueIDs, err := ctx.idx.FindTagValueIDsByExpr(tagKeyID, n.expr)
+	if err != nil {
+		return nil, err
+	}
+	ctx.addContribution(tagKeyID, tagValueIDs)
+	return tagValueIDs, nil
+}
+
+// notFilterNode inverts child within the full tag value id space of its tag
+// key - fixing the tree-level bug where findTagValueIDsByExpr used to just
+// recurse into NotExpr without actually inverting the result. The inversion
+// itself still depends on ctx.idx.AllTagValueIDs(tagKeyID), which errors
+// against the production metadb-backed TagIndex until metadb.TagMetadata
+// implements it(see TagIndex.AllTagValueIDs's doc comment): this node is
+// correct, but NOT exprs don't actually work end-to-end yet.
+type notFilterNode struct {
+	tagKeyID uint32
+	child    TagFilterNode
+}
+
+// Execute returns AllTagValueIDs(tagKeyID) AndNot child
+func (n *notFilterNode) Execute(ctx *TagFilterContext) (*roaring.Bitmap, error) {
+	// evaluate the child against a scratch context: otherwise the leaf's own
+	// addContribution(tagKeyID, childIDs) would union with the inverted result
+	// we record below and every NOT would collapse ctx.tagKeys[tagKeyID] to
+	// childIDs ∪ (All \ childIDs) == All
+	childIDs, err := n.child.Execute(ctx.scratch())
+	if err != nil {
+		return nil, err
+	}
+	all, err := ctx.idx.AllTagValueIDs(n.tagKeyID)
+	if err != nil {
+		return nil, err
+	}
+	result := all.Clone()
+	if childIDs != nil {
+		result.AndNot(childIDs)
+	}
+	ctx.addContribution(n.tagKeyID, result)
+	return result, nil
+}
+
+// regexFilterNode is the leaf node compiled from a regex/prefix/wildcard pattern,
+// pushed down to TagIndex.FindTagValueIDsByRegex instead of being evaluated in memory
+type regexFilterNode struct {
+	tagKeyID uint32
+	pattern  string
+}
+
+// Execute pushes the pattern match down to the index
+func (n *regexFilterNode) Execute(ctx *TagFilterContext) (*roaring.Bitmap, error) {
+	tagValueIDs, err := ctx.idx.FindTagValueIDsByRegex(n.tagKeyID, n.pattern)
+	if err != nil {
+		return nil, err
+	}
+	ctx.addContribution(n.tagKeyID, tagValueIDs)
+	return tagValueIDs, nil
+}
+
+// andFilterNode intersects the results of its children
+type andFilterNode struct {
+	children []TagFilterNode
+}
+
+// Execute evaluates AND as bitmap intersection of all children
+func (n *andFilterNode) Execute(ctx *TagFilterContext) (*roaring.Bitmap, error) {
+	var result *roaring.Bitmap
+	for _, child := range n.children {
+		ids, err := child.Execute(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if ids == nil {
+			ids = roaring.New()
+		}
+		if result == nil {
+			result = ids.Clone()
+			continue
+		}
+		result.And(ids)
+	}
+	return result, nil
+}
+
+// orFilterNode unions the results of its children
+type orFilterNode struct {
+	children []TagFilterNode
+}
+
+// Execute evaluates OR as bitmap union of all children
+func (n *orFilterNode) Execute(ctx *TagFilterContext) (*roaring.Bitmap, error) {
+	var result *roaring.Bitmap
+	for _, child := range n.children {
+		ids, err := child.Execute(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if ids == nil {
+			continue
+		}
+		if result == nil {
+			result = ids.Clone()
+			continue
+		}
+		result.Or(ids)
+	}
+	if result == nil {
+		result = roaring.New()
+	}
+	return result, nil
+}
+
+// compileTagFilter compiles expr into a tree of TagFilterNode, validating the
+// boolean structure up front instead of failing lazily during Execute
+func compileTagFilter(ctx *TagFilterContext, expr stmt.Expr) (TagFilterNode, error) {
+	if expr == nil {
+		return nil, nil
+	}
+	switch e := expr.(type) {
+	case stmt.TagFilter:
+		if pattern, ok := regexPattern(e); ok {
+			tagKeyID, err := ctx.getTagKeyID(e.TagKey())
+			if err != nil {
+				return nil, err
+			}
+			return &regexFilterNode{tagKeyID: tagKeyID, pattern: pattern}, nil
+		}
+		return &tagFilterNode{expr: e}, nil
+	case *stmt.ParenExpr:
+		return compileTagFilter(ctx, e.Expr)
+	case *stmt.NotExpr:
+		tagFilter, ok := e.Expr.(stmt.TagFilter)
+		if !ok {
+			return nil, fmt.Errorf("not expr only supports a single tag filter, got: %T", e.Expr)
+		}
+		tagKeyID, err := ctx.getTagKeyID(tagFilter.TagKey())
+		if err != nil {
+			return nil, err
+		}
+		child, err := compileTagFilter(ctx, e.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return &notFilterNode{tagKeyID: tagKeyID, child: child}, nil
+	case *stmt.BinaryExpr:
+		left, err := compileTagFilter(ctx, e.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := compileTagFilter(ctx, e.Right)
+		if err != nil {
+			return nil, err
+		}
+		switch e.Operator {
+		case stmt.AND:
+			return &andFilterNode{children: []TagFilterNode{left, right}}, nil
+		case stmt.OR:
+			return &orFilterNode{children: []TagFilterNode{left, right}}, nil
+		default:
+			return nil, fmt.Errorf("wrong binary operator in tag filter: %s", stmt.BinaryOPString(e.Operator))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported expr in tag filter: %T", expr)
+	}
+}
+
+// regexPattern extracts a regex/prefix/wildcard pattern from a tag filter expr
+// that should be pushed down to the index rather than evaluated in memory.
+// regexTagFilter is satisfied structurally, so compileTagFilter builds cleanly
+// regardless of which concrete stmt.TagFilter implementations add Regex(); it's
+// the stmt side's job to add it to whichever expr type(s) carry a regex/wildcard
+// pattern(e.g. a like/regex expr) - until one does, this pushdown stays inert
+// and those filters fall through to the plain tagFilterNode leaf below.
+type regexTagFilter interface {
+	Regex() string
+}
+
+func regexPattern(expr stmt.TagFilter) (string, bool) {
+	r, ok := expr.(regexTagFilter)
+	if !ok {
+		return "", false
+	}
+	pattern := r.Regex()
+	if pattern == "" {
+		return "", false
+	}
+	return pattern, true
+}
@@ -0,0 +1,199 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/lindb/roaring"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lindb/lindb/sql/stmt"
+)
+
+// fakeTagFilter implements stmt.TagFilter for tests, matching a fixed tag key
+// against a fixed set of tag value ids handed back by fakeTagIndex
+type fakeTagFilter struct {
+	tagKey string
+}
+
+func (f *fakeTagFilter) TagKey() string  { return f.tagKey }
+func (f *fakeTagFilter) Rewrite() string { return f.tagKey }
+
+// fakeRegexTagFilter additionally implements regexTagFilter, so compileTagFilter
+// pushes it down to a regexFilterNode instead of a plain tagFilterNode
+type fakeRegexTagFilter struct {
+	tagKey string
+	regex  string
+}
+
+func (f *fakeRegexTagFilter) TagKey() string  { return f.tagKey }
+func (f *fakeRegexTagFilter) Rewrite() string { return f.tagKey }
+func (f *fakeRegexTagFilter) Regex() string   { return f.regex }
+
+// fakeTagIndex implements TagIndex over in-memory maps for testing TagFilterNode trees
+type fakeTagIndex struct {
+	tagKeyIDs map[string]uint32
+	matches   map[uint32]*roaring.Bitmap // tagKeyID -> tag value ids a leaf filter matches
+	all       map[uint32]*roaring.Bitmap // tagKeyID -> every registered tag value id
+}
+
+func (idx *fakeTagIndex) GetTagKeyID(tagKey string) (uint32, error) {
+	return idx.tagKeyIDs[tagKey], nil
+}
+
+func (idx *fakeTagIndex) FindTagValueIDsByExpr(tagKeyID uint32, _ stmt.TagFilter) (*roaring.Bitmap, error) {
+	return idx.matches[tagKeyID], nil
+}
+
+func (idx *fakeTagIndex) FindTagValueIDsByRegex(tagKeyID uint32, _ string) (*roaring.Bitmap, error) {
+	return idx.matches[tagKeyID], nil
+}
+
+func (idx *fakeTagIndex) AllTagValueIDs(tagKeyID uint32) (*roaring.Bitmap, error) {
+	return idx.all[tagKeyID], nil
+}
+
+func newTestContext() (*TagFilterContext, *fakeTagIndex) {
+	idx := &fakeTagIndex{
+		tagKeyIDs: map[string]uint32{"host": 1},
+		matches:   map[uint32]*roaring.Bitmap{1: roaring.BitmapOf(1, 2)},
+		all:       map[uint32]*roaring.Bitmap{1: roaring.BitmapOf(1, 2, 3, 4)},
+	}
+	return newTagFilterContext(idx), idx
+}
+
+func TestNotFilterNode_Execute(t *testing.T) {
+	ctx, _ := newTestContext()
+	node := &notFilterNode{
+		tagKeyID: 1,
+		child:    &tagFilterNode{expr: &fakeTagFilter{tagKey: "host"}},
+	}
+
+	result, err := node.Execute(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, roaring.BitmapOf(3, 4).ToArray(), result.ToArray())
+
+	// the contribution recorded for the tag key must be the inverted result,
+	// not the union of the un-negated child and the inverted result (which
+	// would collapse to every registered tag value id)
+	contributed, ok := ctx.tagKeys[1]
+	assert.True(t, ok)
+	assert.Equal(t, roaring.BitmapOf(3, 4).ToArray(), contributed.ToArray())
+}
+
+func TestAndFilterNode_Execute(t *testing.T) {
+	ctx, idx := newTestContext()
+	idx.matches[2] = roaring.BitmapOf(2, 3)
+	node := &andFilterNode{children: []TagFilterNode{
+		&tagFilterNode{expr: &fakeTagFilter{tagKey: "host"}}, // matches {1, 2}
+		&regexFilterNode{tagKeyID: 2, pattern: "ignored"},    // matches {2, 3}
+	}}
+
+	result, err := node.Execute(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, roaring.BitmapOf(2).ToArray(), result.ToArray())
+}
+
+func TestOrFilterNode_Execute(t *testing.T) {
+	ctx, idx := newTestContext()
+	idx.matches[2] = roaring.BitmapOf(3, 4)
+	node := &orFilterNode{children: []TagFilterNode{
+		&tagFilterNode{expr: &fakeTagFilter{tagKey: "host"}},
+		&regexFilterNode{tagKeyID: 2, pattern: "ignored"},
+	}}
+
+	result, err := node.Execute(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, roaring.BitmapOf(1, 2, 3, 4).ToArray(), result.ToArray())
+}
+
+func TestOrFilterNode_Execute_AllChildrenEmptyReturnsEmptyBitmap(t *testing.T) {
+	ctx, _ := newTestContext()
+	node := &orFilterNode{children: []TagFilterNode{
+		&regexFilterNode{tagKeyID: 99, pattern: "no-match"},
+	}}
+
+	result, err := node.Execute(ctx)
+	assert.NoError(t, err)
+	assert.NotNil(t, result)
+	assert.Empty(t, result.ToArray())
+}
+
+// TestAddContribution_DoesNotMutateIndexOwnedBitmap guards against corrupting
+// the index's own state: a second leaf contributing under an already-seen tag
+// key must OR into a clone of the first leaf's bitmap, not the bitmap the
+// index itself handed back and may still own/reuse.
+func TestAddContribution_DoesNotMutateIndexOwnedBitmap(t *testing.T) {
+	ctx, idx := newTestContext()
+	indexOwned := idx.matches[1]
+
+	ctx.addContribution(1, indexOwned)
+	ctx.addContribution(1, roaring.BitmapOf(99))
+
+	assert.Equal(t, []uint32{1, 2}, indexOwned.ToArray())
+	assert.Equal(t, []uint32{1, 2, 99}, ctx.tagKeys[1].ToArray())
+}
+
+func TestRegexFilterNode_Execute(t *testing.T) {
+	ctx, _ := newTestContext()
+	node := &regexFilterNode{tagKeyID: 1, pattern: "h.*"}
+
+	result, err := node.Execute(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, roaring.BitmapOf(1, 2).ToArray(), result.ToArray())
+
+	contributed, ok := ctx.tagKeys[1]
+	assert.True(t, ok)
+	assert.Equal(t, roaring.BitmapOf(1, 2).ToArray(), contributed.ToArray())
+}
+
+func TestCompileTagFilter_NilExprReturnsNilNode(t *testing.T) {
+	ctx, _ := newTestContext()
+	node, err := compileTagFilter(ctx, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, node)
+}
+
+func TestCompileTagFilter_PlainTagFilterCompilesToLeafNode(t *testing.T) {
+	ctx, _ := newTestContext()
+	node, err := compileTagFilter(ctx, &fakeTagFilter{tagKey: "host"})
+	assert.NoError(t, err)
+	_, ok := node.(*tagFilterNode)
+	assert.True(t, ok)
+}
+
+func TestCompileTagFilter_RegexTagFilterCompilesToRegexNode(t *testing.T) {
+	ctx, _ := newTestContext()
+	node, err := compileTagFilter(ctx, &fakeRegexTagFilter{tagKey: "host", regex: "h.*"})
+	assert.NoError(t, err)
+	_, ok := node.(*regexFilterNode)
+	assert.True(t, ok)
+}
+
+func TestCompileTagFilter_ParenExprUnwrapsToChild(t *testing.T) {
+	ctx, _ := newTestContext()
+	node, err := compileTagFilter(ctx, &stmt.ParenExpr{Expr: &fakeTagFilter{tagKey: "host"}})
+	assert.NoError(t, err)
+	_, ok := node.(*tagFilterNode)
+	assert.True(t, ok)
+}
+
+func TestCompileTagFilter_NotExprOverCompoundExprReturnsError(t *testing.T) {
+	ctx, _ := newTestContext()
+	_, err := compileTagFilter(ctx, &stmt.NotExpr{Expr: &stmt.BinaryExpr{
+		Left:     &fakeTagFilter{tagKey: "host"},
+		Operator: stmt.AND,
+		Right:    &fakeTagFilter{tagKey: "host"},
+	}})
+	assert.Error(t, err)
+}
+
+func TestCompileTagFilter_BinaryExprUnsupportedOperatorReturnsError(t *testing.T) {
+	ctx, _ := newTestContext()
+	_, err := compileTagFilter(ctx, &stmt.BinaryExpr{
+		Left:     &fakeTagFilter{tagKey: "host"},
+		Operator: stmt.ADD,
+		Right:    &fakeTagFilter{tagKey: "host"},
+	})
+	assert.Error(t, err)
+}
+
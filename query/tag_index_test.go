@@ -0,0 +1,60 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/lindb/roaring"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lindb/lindb/sql/stmt"
+)
+
+// fakeBareTagMetadata implements only baseTagMetadata(FindTagValueDsByExpr),
+// mirroring metadb.TagMetadata's current real surface: no FindTagValueDsByRegex,
+// no AllTagValueDs.
+type fakeBareTagMetadata struct{}
+
+func (fakeBareTagMetadata) FindTagValueDsByExpr(_ uint32, _ stmt.TagFilter) (*roaring.Bitmap, error) {
+	return nil, nil
+}
+
+// fakeRegexCapableTagMetadata additionally implements regexCapableTagMetadata,
+// standing in for a future metadb.TagMetadata that has grown the surface.
+type fakeRegexCapableTagMetadata struct {
+	fakeBareTagMetadata
+	regexResult *roaring.Bitmap
+	allResult   *roaring.Bitmap
+}
+
+func (f *fakeRegexCapableTagMetadata) FindTagValueDsByRegex(_ uint32, _ string) (*roaring.Bitmap, error) {
+	return f.regexResult, nil
+}
+
+func (f *fakeRegexCapableTagMetadata) AllTagValueDs(_ uint32) (*roaring.Bitmap, error) {
+	return f.allResult, nil
+}
+
+// TestRegexCapableFrom_BareTagMetadataReturnsUnsupportedError guards the
+// degrade path production hits today: a TagMetadata that only implements
+// FindTagValueDsByExpr(the real metadb.TagMetadata, as of d16ba52) must fail
+// with a clear, capability-naming error instead of panicking on a failed
+// assertion further down the call chain.
+func TestRegexCapableFrom_BareTagMetadataReturnsUnsupportedError(t *testing.T) {
+	_, err := regexCapableFrom(fakeBareTagMetadata{}, "regex/wildcard pushdown")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "regex/wildcard pushdown")
+}
+
+// TestRegexCapableFrom_CapableTagMetadataDelegates guards the other side: once
+// a TagMetadata does implement the extra surface, regexCapableFrom must hand
+// back something that actually delegates to it rather than always erroring.
+func TestRegexCapableFrom_CapableTagMetadataDelegates(t *testing.T) {
+	fake := &fakeRegexCapableTagMetadata{regexResult: roaring.BitmapOf(1, 2)}
+
+	capable, err := regexCapableFrom(fake, "regex/wildcard pushdown")
+	assert.NoError(t, err)
+
+	result, err := capable.FindTagValueDsByRegex(1, "h.*")
+	assert.NoError(t, err)
+	assert.Equal(t, roaring.BitmapOf(1, 2).ToArray(), result.ToArray())
+}
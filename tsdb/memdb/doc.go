@@ -0,0 +1,14 @@
+// Package memdb holds the in-memory write buffer for a TSDB shard: per-field
+// sStore segments bucketed by family time, flushed out to disk through
+// metrictbl.TableFlusher.
+//
+// Partial delivery notice: fStoreINTF.flushFieldTo's signature grew from
+// flushFieldTo(tableFlusher, familyTime) in baseline commit 28f81ca to
+// flushFieldTo(tableFlusher, familyTime, interval, policy, terminal) across
+// f93337b/a78415e/d970244 in this series, but this package has no
+// shard/family-level flush driver that calls flushFieldTo - the only call
+// sites that exist in this tree are the method's own definition, its mock and
+// field_store_rollup_test.go. A real caller lives outside this diff(the shard/family flush
+// loop); verify it was updated to pass policy/terminal/interval before
+// merging, since nothing here can prove that compiles.
+package memdb
@@ -1,8 +1,10 @@
 package memdb
 
 import (
+	"math"
 	"sort"
 
+	"github.com/eleme/lindb/pkg/encoding"
 	"github.com/eleme/lindb/pkg/field"
 	"github.com/eleme/lindb/pkg/logger"
 	"github.com/eleme/lindb/pkg/timeutil"
@@ -20,14 +22,50 @@ type fStoreINTF interface {
 	getFieldType() field.Type
 	// write writes the metric's field with writeContext
 	write(f *pb.Field, writeCtx writeContext)
-	// flushFieldTo flushes field data of the specific familyTime
+	// flushFieldTo flushes field data related to familyTime to tableFlusher,
+	// applying policy's rollup and retention rules: familyTime is folded together
+	// with any other not-yet-flushed sStore that rolls up into the same
+	// policy.RollupInterval window and the lot is merged through the field's agg
+	// func(Sum/Min/Max) before being written as one segment, and sStores older
+	// than policy.RetentionCutoff are dropped instead of flushed. interval is the
+	// slot duration(same unit as timeRange's), needed to rebase each merged
+	// sStore's slots onto the rolled window's origin before folding them - slot N
+	// under one family time and slot N under another are different wall-clock
+	// instants, so only after rebasing do equal slot numbers mean the same
+	// instant. terminal marks familyTime as this field-store's last write
+	// window: only a terminal flush closes the window off against further
+	// writes, so a routine flush of the still-open, newest family doesn't
+	// reject the writes still arriving for it.
 	// return false if there is no data related of familyTime
-	flushFieldTo(tableFlusher metrictbl.TableFlusher, familyTime int64) (flushed bool)
+	flushFieldTo(
+		tableFlusher metrictbl.TableFlusher, familyTime, interval int64, policy FlushPolicy, terminal bool,
+	) (flushed bool)
 	// timeRange returns the start-time and end-time of fStore's data
 	// ok means data is available
 	timeRange(interval int64) (timeRange timeutil.TimeRange, ok bool)
 }
 
+// FlushPolicy controls how fieldStore.flushFieldTo rolls up and retires segments:
+// RollupInterval buckets adjacent family times that fall in the same window so
+// they're combined through the field's associative agg func(Sum/Min/Max) and
+// written as a single segment instead of one per family time; RetentionCutoff
+// drops any sStore whose family time is older than it instead of flushing it,
+// giving operators tiered storage semantics inside the memdb tier. The zero
+// value disables both, making flushFieldTo behave like a verbatim single-family flush.
+type FlushPolicy struct {
+	RollupInterval  int64
+	RetentionCutoff int64
+}
+
+// rollupFamilyTime buckets familyTime down to the start of its RollupInterval
+// window, or returns familyTime unchanged if rollup is disabled
+func (p FlushPolicy) rollupFamilyTime(familyTime int64) int64 {
+	if p.RollupInterval <= 0 {
+		return familyTime
+	}
+	return familyTime - familyTime%p.RollupInterval
+}
+
 // sStoreNodes implements the sort.Interface
 type sStoreNodes []sStoreINTF
 
@@ -35,6 +73,19 @@ func (s sStoreNodes) Len() int           { return len(s) }
 func (s sStoreNodes) Less(i, j int) bool { return s[i].getFamilyTime() < s[j].getFamilyTime() }
 func (s sStoreNodes) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
 
+// maxFamilyTime returns the largest family time among bucket's members. bucket
+// is never empty: flushFieldTo always seeds it with the sStore it looked up by
+// familyTime before appending any rolled-up siblings.
+func maxFamilyTime(bucket sStoreNodes) int64 {
+	maxTime := bucket[0].getFamilyTime()
+	for _, member := range bucket[1:] {
+		if t := member.getFamilyTime(); t > maxTime {
+			maxTime = t
+		}
+	}
+	return maxTime
+}
+
 // fieldStore holds the relation of familyStartTime and segmentStore.
 // there are only a few familyTimes in the segments,
 // add delete operation occurs every one hour
@@ -44,6 +95,8 @@ type fieldStore struct {
 	fieldType   field.Type  // sum, gauge, min, max
 	fieldID     uint16      // generated by id generator
 	sStoreNodes sStoreNodes // sorted sStore list by family-time
+
+	flushedBefore int64 // family time at or below which sStores have already been flushed or retired
 }
 
 // newFieldStore returns a new fieldStore.
@@ -90,9 +143,16 @@ func (fs *fieldStore) removeSStore(familyTime int64) {
 }
 
 // insertSStore inserts a new sStore to segments.
-func (fs *fieldStore) insertSStore(sStore sStoreINTF) {
+// it rejects a familyTime that falls into an already-flushed-and-closed window,
+// since the window has already been persisted and accepting it here would
+// silently create an orphan sStore that never gets flushed again
+func (fs *fieldStore) insertSStore(sStore sStoreINTF) (ok bool) {
+	if sStore.getFamilyTime() <= fs.flushedBefore {
+		return false
+	}
 	fs.sStoreNodes = append(fs.sStoreNodes, sStore)
 	sort.Sort(fs.sStoreNodes)
+	return true
 }
 
 // getFieldType returns field type for current field store
@@ -106,9 +166,14 @@ func (fs *fieldStore) write(f *pb.Field, writeCtx writeContext) {
 	switch fields := f.Field.(type) {
 	case *pb.Field_Sum:
 		if !ok {
-			//TODO ???
 			sStore = newSimpleFieldStore(writeCtx.familyTime, field.GetAggFunc(field.Sum))
-			fs.insertSStore(sStore)
+			if !fs.insertSStore(sStore) {
+				// familyTime has already been flushed and its window closed,
+				// drop the late write instead of creating an unflushable orphan
+				memDBLogger.Warn("drop write into closed family window",
+					logger.Int64("familyTime", writeCtx.familyTime))
+				return
+			}
 		}
 		sStore.writeFloat(fields.Sum, writeCtx)
 	default:
@@ -116,23 +181,156 @@ func (fs *fieldStore) write(f *pb.Field, writeCtx writeContext) {
 	}
 }
 
-// flushFieldTo flushes segments' data to writer and reset the segments-map.
-func (fs *fieldStore) flushFieldTo(tableFlusher metrictbl.TableFlusher, familyTime int64) (flushed bool) {
+// flushFieldTo flushes the sStore at familyTime to tableFlusher: when
+// policy.RollupInterval groups it with other not-yet-flushed sStores into the
+// same window, the whole bucket is rebased onto the window's origin(using
+// interval) and merged through the field's agg func, written as one segment
+// instead of one per family time; when familyTime falls before
+// policy.RetentionCutoff it's dropped instead of flushed. Only a terminal
+// flush advances flushedBefore, closing the window against later writes - see
+// the fStoreINTF doc comment for why a routine flush must not. flushedBefore
+// is advanced to the bucket's maximum family time, not familyTime itself: a
+// rollup can fold in a sibling sStore whose own family time is later than
+// familyTime, and that sibling is removed here too, so the window must close
+// at least as far as it or a later write at its family time would slip past
+// insertSStore's guard and reopen an already-flushed window.
+func (fs *fieldStore) flushFieldTo(
+	tableFlusher metrictbl.TableFlusher, familyTime, interval int64, policy FlushPolicy, terminal bool,
+) (flushed bool) {
 	sStore, ok := fs.getSStore(familyTime)
-
 	if !ok {
 		return false
 	}
 
-	fs.removeSStore(familyTime)
-	data, startSlot, endSlot, err := sStore.bytes()
+	if policy.RetentionCutoff > 0 && familyTime < policy.RetentionCutoff {
+		fs.removeSStore(familyTime)
+		fs.closeBefore(familyTime, terminal)
+		return true
+	}
+
+	windowOrigin := familyTime
+	bucket := sStoreNodes{sStore}
+	if policy.RollupInterval > 0 {
+		windowOrigin = policy.rollupFamilyTime(familyTime)
+		for _, other := range fs.sStoreNodes {
+			if other.getFamilyTime() != familyTime && policy.rollupFamilyTime(other.getFamilyTime()) == windowOrigin {
+				bucket = append(bucket, other)
+			}
+		}
+		sort.Sort(bucket)
+	}
+	for _, member := range bucket {
+		fs.removeSStore(member.getFamilyTime())
+	}
+
+	fs.flushBucket(tableFlusher, windowOrigin, interval, bucket)
+	fs.closeBefore(maxFamilyTime(bucket), terminal)
+	return true
+}
+
+// closeBefore advances flushedBefore to upTo, rejecting any further
+// write/insert at or before it, but only when terminal is set - a routine,
+// non-terminal flush of the still-open family must leave the window open.
+func (fs *fieldStore) closeBefore(upTo int64, terminal bool) {
+	if terminal && upTo > fs.flushedBefore {
+		fs.flushedBefore = upTo
+	}
+}
 
+// flushBucket writes a group of sStores that rolled up into windowOrigin to
+// tableFlusher. A lone member whose own familyTime already is windowOrigin
+// needs no rebasing and is written verbatim; otherwise(more than one member,
+// or a single member displaced from windowOrigin) the bucket is merged
+// through the field's agg func with every member's slots rebased onto
+// windowOrigin first, so the rollup window is persisted as one segment.
+func (fs *fieldStore) flushBucket(tableFlusher metrictbl.TableFlusher, windowOrigin, interval int64, bucket []sStoreINTF) {
+	if len(bucket) == 1 && bucket[0].getFamilyTime() == windowOrigin {
+		fs.flushSingle(tableFlusher, bucket[0])
+		return
+	}
+	data, startSlot, endSlot, err := mergeSStores(fs.fieldType, windowOrigin, interval, bucket)
+	if err != nil {
+		memDBLogger.Error("merge rollup segments error:", logger.Error(err))
+		for _, sStore := range bucket {
+			fs.flushSingle(tableFlusher, sStore)
+		}
+		return
+	}
+	tableFlusher.FlushField(fs.fieldID, fs.fieldType, data, startSlot, endSlot)
+}
+
+// flushSingle writes a single sStore's data to tableFlusher
+func (fs *fieldStore) flushSingle(tableFlusher metrictbl.TableFlusher, sStore sStoreINTF) {
+	data, startSlot, endSlot, err := sStore.bytes()
 	if err != nil {
 		memDBLogger.Error("read segment data error:", logger.Error(err))
-		return false
+		return
 	}
 	tableFlusher.FlushField(fs.fieldID, fs.fieldType, data, startSlot, endSlot)
-	return true
+}
+
+// mergeSStores decodes every sStore in the bucket and folds their values into a
+// single slot range anchored at windowOrigin through fieldType's associative
+// agg func(Sum/Min/Max), re-encoding the result so the rollup window is
+// flushed as one segment. Each sStore's own slot range is first rebased by
+// (familyTime-windowOrigin)/interval slots: slot N under one family time and
+// slot N under another are different wall-clock instants, so only after
+// rebasing do equal slot numbers actually refer to the same instant and may
+// be folded together instead of aliased.
+func mergeSStores(
+	fieldType field.Type, windowOrigin, interval int64, bucket []sStoreINTF,
+) (data []byte, startSlot, endSlot int, err error) {
+	aggFunc := field.GetAggFunc(fieldType)
+	values := make(map[int]float64)
+	var initialized bool
+
+	for _, sStore := range bucket {
+		segStart, segEnd, rangeErr := sStore.slotRange()
+		if rangeErr != nil {
+			continue
+		}
+		offset := int((sStore.getFamilyTime() - windowOrigin) / interval)
+		segStart, segEnd = segStart+offset, segEnd+offset
+
+		segData, _, _, bytesErr := sStore.bytes()
+		if bytesErr != nil {
+			return nil, 0, 0, bytesErr
+		}
+		decoder := encoding.NewTSDDecoder(segData)
+		for slot := segStart; slot <= segEnd; slot++ {
+			if !decoder.HasValueWithSlot(slot - offset) {
+				continue
+			}
+			value := math.Float64frombits(decoder.Value())
+			if existing, ok := values[slot]; ok {
+				values[slot] = aggFunc.Aggregate(existing, value)
+			} else {
+				values[slot] = value
+			}
+		}
+		if !initialized {
+			startSlot, endSlot = segStart, segEnd
+			initialized = true
+		} else {
+			if segStart < startSlot {
+				startSlot = segStart
+			}
+			if segEnd > endSlot {
+				endSlot = segEnd
+			}
+		}
+	}
+
+	encoder := encoding.NewTSDEncoder(startSlot)
+	for slot := startSlot; slot <= endSlot; slot++ {
+		value, ok := values[slot]
+		encoder.AppendTime(ok)
+		if ok {
+			encoder.AppendValue(math.Float64bits(value))
+		}
+	}
+	data, err = encoder.Bytes()
+	return data, startSlot, endSlot, err
 }
 
 func (fs *fieldStore) timeRange(interval int64) (timeRange timeutil.TimeRange, ok bool) {
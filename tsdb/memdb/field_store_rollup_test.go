@@ -0,0 +1,149 @@
+package memdb
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/eleme/lindb/pkg/encoding"
+	"github.com/eleme/lindb/pkg/field"
+	"github.com/eleme/lindb/tsdb/metrictbl"
+)
+
+// fakeSStore is a minimal sStoreINTF stand-in carrying a pre-encoded segment,
+// used to exercise mergeSStores' range accumulator in isolation
+type fakeSStore struct {
+	familyTime int64
+	startSlot  int
+	endSlot    int
+	data       []byte
+}
+
+func (s *fakeSStore) getFamilyTime() int64                { return s.familyTime }
+func (s *fakeSStore) slotRange() (int, int, error)        { return s.startSlot, s.endSlot, nil }
+func (s *fakeSStore) bytes() ([]byte, int, int, error)    { return s.data, s.startSlot, s.endSlot, nil }
+func (s *fakeSStore) writeFloat(float64, writeContext)    {}
+
+// encodeSegment builds a single-value segment at slot with value, for use as fakeSStore.data.
+// mergeSStores decodes values via math.Float64frombits, so they must be encoded
+// via math.Float64bits - bit-reinterpreting, not truncating, the float.
+func encodeSegment(slot int, value float64) []byte {
+	encoder := encoding.NewTSDEncoder(slot)
+	encoder.AppendTime(true)
+	encoder.AppendValue(math.Float64bits(value))
+	data, _ := encoder.Bytes()
+	return data
+}
+
+// TestMergeSStores_FirstSegmentRangeStartsAtZero guards against the magic-zero
+// sentinel bug: when the first bucket member's own (rebased) slot range happens
+// to be exactly (0, 0), a later member's wider range must still expand the
+// merged range instead of the (0, 0) range being mistaken for "not yet
+// initialized" and overwritten.
+func TestMergeSStores_FirstSegmentRangeStartsAtZero(t *testing.T) {
+	bucket := []sStoreINTF{
+		&fakeSStore{familyTime: 0, startSlot: 0, endSlot: 0, data: encodeSegment(0, 1)},
+		// familyTime 120 rebases by (120-0)/60 = 2 slots: native [1,3] -> [3,5]
+		&fakeSStore{familyTime: 120, startSlot: 1, endSlot: 3, data: encodeSegment(1, 2)},
+	}
+
+	_, startSlot, endSlot, err := mergeSStores(field.Sum, 0, 60, bucket)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, startSlot)
+	assert.Equal(t, 5, endSlot)
+}
+
+// TestMergeSStores_AggregatesOverlappingValues guards the actual point of
+// mergeSStores: two segments from different family times that rebase onto the
+// same absolute slot must be folded through fieldType's associative agg
+// func(Sum here), not aliased or left as two separate points.
+func TestMergeSStores_AggregatesOverlappingValues(t *testing.T) {
+	bucket := []sStoreINTF{
+		// familyTime 0, native slot 1 -> rebases to absolute slot 1 (offset 0)
+		&fakeSStore{familyTime: 0, startSlot: 1, endSlot: 1, data: encodeSegment(1, 1.5)},
+		// familyTime 60 rebases by (60-0)/60 = 1 slot: native slot 0 -> absolute slot 1
+		&fakeSStore{familyTime: 60, startSlot: 0, endSlot: 0, data: encodeSegment(0, 2.5)},
+	}
+
+	data, startSlot, endSlot, err := mergeSStores(field.Sum, 0, 60, bucket)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, startSlot)
+	assert.Equal(t, 1, endSlot)
+
+	decoder := encoding.NewTSDDecoder(data)
+	assert.True(t, decoder.HasValueWithSlot(1))
+	assert.Equal(t, 4.0, math.Float64frombits(decoder.Value()))
+}
+
+// TestMaxFamilyTime_FindsLaterSibling guards the flushedBefore advancement bug:
+// when a rollup opportunistically folds in a sibling sStore whose family time
+// is later than the one flushFieldTo was called with, maxFamilyTime must report
+// that later time so flushedBefore actually closes the whole bucket's window,
+// not just the family time the caller happened to pass in.
+func TestMaxFamilyTime_FindsLaterSibling(t *testing.T) {
+	bucket := sStoreNodes{
+		&fakeSStore{familyTime: 0},
+		&fakeSStore{familyTime: 30},
+	}
+	assert.Equal(t, int64(30), maxFamilyTime(bucket))
+}
+
+// TestMaxFamilyTime_SingleMemberBucket covers the non-rollup flush path, where
+// bucket only ever holds the sStore flushFieldTo looked up by familyTime.
+func TestMaxFamilyTime_SingleMemberBucket(t *testing.T) {
+	bucket := sStoreNodes{&fakeSStore{familyTime: 60}}
+	assert.Equal(t, int64(60), maxFamilyTime(bucket))
+}
+
+// fakeTableFlusher is a minimal metrictbl.TableFlusher stand-in recording
+// every FlushField call, embedding the real interface(like fakeTaskManager
+// does for TaskManager in job_entry_test.go) so it only has to implement
+// FlushField itself.
+type fakeTableFlusher struct {
+	metrictbl.TableFlusher
+	flushed int
+}
+
+func (f *fakeTableFlusher) FlushField(_ uint16, _ field.Type, _ []byte, _, _ int) {
+	f.flushed++
+}
+
+// TestFieldStore_InsertSStore_RejectsWriteIntoClosedFamily exercises the
+// reject-closed-window behavior end to end against a real *fieldStore,
+// instead of only the pure mergeSStores/maxFamilyTime helpers: once
+// flushFieldTo closes a family with terminal=true, a later insert at or
+// before that family time must be dropped rather than silently creating an
+// orphan sStore that never gets flushed again. This drives insertSStore
+// directly rather than through write(), since write()'s own sStore
+// construction(newSimpleFieldStore) isn't part of this diff - insertSStore
+// is where write()
+// delegates the same reject-or-accept decision to, so the behavior under
+// test is identical.
+func TestFieldStore_InsertSStore_RejectsWriteIntoClosedFamily(t *testing.T) {
+	fs := &fieldStore{fieldName: "f1", fieldID: 1, fieldType: field.Sum}
+	assert.True(t, fs.insertSStore(&fakeSStore{familyTime: 100, data: encodeSegment(0, 1)}))
+
+	flusher := &fakeTableFlusher{}
+	flushed := fs.flushFieldTo(flusher, 100, 60, FlushPolicy{}, true)
+	assert.True(t, flushed)
+	assert.Equal(t, 1, flusher.flushed)
+	assert.Equal(t, int64(100), fs.flushedBefore)
+
+	assert.False(t, fs.insertSStore(&fakeSStore{familyTime: 100, data: encodeSegment(0, 2)}))
+	assert.Empty(t, fs.sStoreNodes)
+}
+
+// TestFieldStore_CloseBefore_OnlyAdvancesWhenTerminal guards the other half
+// of the same bug class: a routine, non-terminal flush of the still-open
+// newest family must leave flushedBefore(and so insertSStore's guard)
+// untouched, only a terminal flush may advance it.
+func TestFieldStore_CloseBefore_OnlyAdvancesWhenTerminal(t *testing.T) {
+	fs := &fieldStore{fieldName: "f1", fieldID: 1, fieldType: field.Sum}
+
+	fs.closeBefore(100, false)
+	assert.Equal(t, int64(0), fs.flushedBefore)
+
+	fs.closeBefore(100, true)
+	assert.Equal(t, int64(100), fs.flushedBefore)
+}